@@ -0,0 +1,110 @@
+package deployer
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// validateEventRule compiles ReasonRegex/MessageRegex so a malformed pattern is caught at
+// migration time instead of silently never matching at evaluation time, and fills in the
+// MinCount default for rules that didn't set one explicitly.
+func validateEventRule(rule *v3.EventRule) error {
+	if rule.MinCount == 0 {
+		rule.MinCount = 1
+	}
+
+	if rule.ReasonRegex != "" {
+		if _, err := regexp.Compile(rule.ReasonRegex); err != nil {
+			return errors.Wrapf(err, "invalid reasonRegex %q", rule.ReasonRegex)
+		}
+	}
+	if rule.MessageRegex != "" {
+		if _, err := regexp.Compile(rule.MessageRegex); err != nil {
+			return errors.Wrapf(err, "invalid messageRegex %q", rule.MessageRegex)
+		}
+	}
+	return nil
+}
+
+// matchesEventRule reports whether event, with involvedObjectLabels already resolved by the
+// caller from the involved object (an Event carries no labels of its own), satisfies rule.
+// A malformed ReasonRegex/MessageRegex can't reach here because validateEventRule already
+// rejected it at migration time, so the regexes are compiled fresh per call rather than
+// cached.
+func matchesEventRule(event *corev1.Event, involvedObjectLabels map[string]string, rule *v3.EventRule) bool {
+	if rule.EventType != "" && event.Type != rule.EventType {
+		return false
+	}
+	if rule.ResourceKind != "" && event.InvolvedObject.Kind != rule.ResourceKind {
+		return false
+	}
+	if rule.SourceComponent != "" && event.Source.Component != rule.SourceComponent {
+		return false
+	}
+	if rule.ReasonRegex != "" {
+		matched, err := regexp.MatchString(rule.ReasonRegex, event.Reason)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	if rule.MessageRegex != "" {
+		matched, err := regexp.MatchString(rule.MessageRegex, event.Message)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	if sel := rule.InvolvedObjectSelector; sel != nil {
+		if sel.Namespace != "" && event.InvolvedObject.Namespace != sel.Namespace {
+			return false
+		}
+		if len(sel.Selector) > 0 && !labels.SelectorFromSet(sel.Selector).Matches(labels.Set(involvedObjectLabels)) {
+			return false
+		}
+	}
+	return true
+}
+
+// eventRuleTracker counts matching events against a rule's MinCount within a trailing
+// WindowSeconds window, mirroring podRestartTracker's ring-of-samples approach so a burst
+// of events well outside the window doesn't keep inflating the count forever. With
+// WindowSeconds=0 no sample is evicted, so the count covers every occurrence observed for
+// the rule key.
+type eventRuleTracker struct {
+	lock    sync.Mutex
+	samples map[string][]time.Time
+}
+
+func newEventRuleTracker() *eventRuleTracker {
+	return &eventRuleTracker{samples: map[string][]time.Time{}}
+}
+
+// Observe records one occurrence of a matching event for ruleKey at now and reports
+// whether the count within rule's WindowSeconds has reached rule.MinCount.
+func (t *eventRuleTracker) Observe(ruleKey string, now time.Time, rule *v3.EventRule) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	window := time.Duration(rule.WindowSeconds) * time.Second
+	samples := append(t.samples[ruleKey], now)
+
+	if window > 0 {
+		cutoff := now.Add(-window)
+		kept := samples[:0]
+		for _, s := range samples {
+			if !s.Before(cutoff) {
+				kept = append(kept, s)
+			}
+		}
+		samples = kept
+	}
+	t.samples[ruleKey] = samples
+
+	return int64(len(samples)) >= rule.MinCount
+}
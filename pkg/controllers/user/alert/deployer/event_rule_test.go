@@ -0,0 +1,86 @@
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMatchesEventRuleFiltersOnTypeKindAndRegex(t *testing.T) {
+	rule := &v3.EventRule{
+		EventType:    "Warning",
+		ResourceKind: "Pod",
+		ReasonRegex:  "^FailedScheduling$",
+		MessageRegex: "insufficient",
+	}
+	event := &corev1.Event{
+		Type:           "Warning",
+		Reason:         "FailedScheduling",
+		Message:        "0/3 nodes are available: insufficient cpu",
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod"},
+	}
+	if !matchesEventRule(event, nil, rule) {
+		t.Fatal("expected event matching type/kind/reason/message to match")
+	}
+
+	wrongReason := event.DeepCopy()
+	wrongReason.Reason = "Unhealthy"
+	if matchesEventRule(wrongReason, nil, rule) {
+		t.Fatal("expected reason mismatch to not match")
+	}
+}
+
+func TestMatchesEventRuleInvolvedObjectSelector(t *testing.T) {
+	rule := &v3.EventRule{
+		InvolvedObjectSelector: &v3.EventObjectSelector{
+			Namespace: "default",
+			Selector:  map[string]string{"app": "web"},
+		},
+	}
+	event := &corev1.Event{InvolvedObject: corev1.ObjectReference{Namespace: "default"}}
+
+	if matchesEventRule(event, map[string]string{"app": "other"}, rule) {
+		t.Fatal("expected label mismatch to not match")
+	}
+	if !matchesEventRule(event, map[string]string{"app": "web"}, rule) {
+		t.Fatal("expected matching namespace and labels to match")
+	}
+
+	event.InvolvedObject.Namespace = "kube-system"
+	if matchesEventRule(event, map[string]string{"app": "web"}, rule) {
+		t.Fatal("expected namespace mismatch to not match")
+	}
+}
+
+func TestEventRuleTrackerFiresOnceMinCountReachedWithinWindow(t *testing.T) {
+	tracker := newEventRuleTracker()
+	rule := &v3.EventRule{MinCount: 3, WindowSeconds: 60}
+
+	now := time.Now()
+	if tracker.Observe("rule-a", now, rule) {
+		t.Fatal("expected no fire below MinCount")
+	}
+	if tracker.Observe("rule-a", now.Add(10*time.Second), rule) {
+		t.Fatal("expected no fire below MinCount")
+	}
+	if !tracker.Observe("rule-a", now.Add(20*time.Second), rule) {
+		t.Fatal("expected fire once MinCount is reached")
+	}
+}
+
+func TestEventRuleTrackerEvictsOldSamples(t *testing.T) {
+	tracker := newEventRuleTracker()
+	rule := &v3.EventRule{MinCount: 2, WindowSeconds: 30}
+
+	now := time.Now()
+	tracker.Observe("rule-a", now, rule)
+
+	// Falls outside the 30s window relative to the first sample, so it should have aged
+	// out and leave only this one occurrence.
+	if tracker.Observe("rule-a", now.Add(5*time.Minute), rule) {
+		t.Fatal("expected no fire once the earlier sample has aged out of the window")
+	}
+}
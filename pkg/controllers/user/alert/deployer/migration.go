@@ -0,0 +1,363 @@
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	alertutil "github.com/rancher/rancher/pkg/controllers/user/alert/common"
+	"github.com/rancher/rancher/pkg/ref"
+	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/norman/controller"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	migratedToAnnotation        = "alerting.cattle.io/migrated-to"
+	migrationReportConfigMap    = "alert-migration-report"
+	migrationReportConfigMapKey = "report"
+)
+
+// MigrationResultStatus is the outcome of migrating a single legacy alert.
+type MigrationResultStatus string
+
+const (
+	MigrationStatusMigrated MigrationResultStatus = "Migrated"
+	MigrationStatusSkipped  MigrationResultStatus = "Skipped"
+	MigrationStatusFailed   MigrationResultStatus = "Failed"
+)
+
+// MigrationResult records what happened when migrating one legacy cluster/project alert.
+type MigrationResult struct {
+	SourceNamespace string                `json:"sourceNamespace"`
+	SourceName      string                `json:"sourceName"`
+	Status          MigrationResultStatus `json:"status"`
+	Error           string                `json:"error,omitempty"`
+}
+
+// MigrationReport is the accumulated, persisted result of one migration pass: one
+// MigrationResult per legacy cluster/project alert it looked at.
+type MigrationReport struct {
+	Results []MigrationResult `json:"results"`
+}
+
+func (r *MigrationReport) migrated(namespace, name string) {
+	r.Results = append(r.Results, MigrationResult{SourceNamespace: namespace, SourceName: name, Status: MigrationStatusMigrated})
+}
+
+func (r *MigrationReport) skipped(namespace, name string) {
+	r.Results = append(r.Results, MigrationResult{SourceNamespace: namespace, SourceName: name, Status: MigrationStatusSkipped})
+}
+
+func (r *MigrationReport) failed(namespace, name string, err error) {
+	r.Results = append(r.Results, MigrationResult{SourceNamespace: namespace, SourceName: name, Status: MigrationStatusFailed, Error: err.Error()})
+}
+
+// Pending returns the number of legacy alerts that still need to be migrated.
+func (r *MigrationReport) Pending() int {
+	pending := 0
+	for _, result := range r.Results {
+		if result.Status == MigrationStatusFailed {
+			pending++
+		}
+	}
+	return pending
+}
+
+// persistReport writes the report as a ConfigMap in cattle-alerting so operators and the
+// UI can see exactly which legacy alerts didn't convert and why.
+func (l *AlertService) persistReport(report *MigrationReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal migration report failed, %v", err)
+	}
+
+	existing, err := l.configMaps.GetNamespaced(legacyAlertmanagerNamespace, migrationReportConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get migration report configmap failed, %v", err)
+		}
+		_, err = l.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      migrationReportConfigMap,
+				Namespace: legacyAlertmanagerNamespace,
+			},
+			Data: map[string]string{migrationReportConfigMapKey: string(data)},
+		})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create migration report configmap failed, %v", err)
+		}
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	if updated.Data == nil {
+		updated.Data = map[string]string{}
+	}
+	updated.Data[migrationReportConfigMapKey] = string(data)
+	if _, err := l.configMaps.Update(updated); err != nil {
+		return fmt.Errorf("update migration report configmap failed, %v", err)
+	}
+	return nil
+}
+
+func (l *AlertService) migrateLegacyClusterAlert(report *MigrationReport) error {
+	oldClusterAlert, err := l.oldClusterAlerts.List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("get old cluster alert failed, %s", err)
+	}
+	for _, v := range oldClusterAlert.Items {
+		if v.Annotations[migratedToAnnotation] != "" {
+			report.skipped(v.Namespace, v.Name)
+			continue
+		}
+
+		if err := l.migrateOneLegacyClusterAlert(&v); err != nil {
+			report.failed(v.Namespace, v.Name, err)
+			continue
+		}
+		report.migrated(v.Namespace, v.Name)
+	}
+	return nil
+}
+
+func (l *AlertService) migrateOneLegacyClusterAlert(v *v3.ClusterAlert) error {
+	migrationGroupName := fmt.Sprintf("migrate-group-%s", v.Name)
+	groupID := alertutil.GetGroupID(l.clusterName, migrationGroupName)
+
+	name := fmt.Sprintf("migrate-%s", v.Name)
+	newClusterRule := &v3.ClusterAlertRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: l.clusterName,
+		},
+		Spec: v3.ClusterAlertRuleSpec{
+			ClusterName: l.clusterName,
+			GroupName:   groupID,
+			CommonRuleField: v3.CommonRuleField{
+				DisplayName: v.Spec.DisplayName,
+				Severity:    v.Spec.Severity,
+				TimingField: v3.TimingField{
+					GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
+					GroupIntervalSeconds:  defaultGroupIntervalSeconds,
+					RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
+				},
+			},
+		},
+	}
+
+	if v.Spec.TargetNode != nil {
+		newClusterRule.Spec.NodeRule = &v3.NodeRule{
+			NodeName:     v.Spec.TargetNode.NodeName,
+			Selector:     v.Spec.TargetNode.Selector,
+			Condition:    v.Spec.TargetNode.Condition,
+			MemThreshold: v.Spec.TargetNode.MemThreshold,
+			CPUThreshold: v.Spec.TargetNode.CPUThreshold,
+		}
+	}
+
+	if v.Spec.TargetEvent != nil {
+		// Legacy TargetEvent has no reason/message filtering, so the migrated rule gets
+		// MinCount=1, WindowSeconds=0 to behave identically to the old "fire on every
+		// matching event" semantics.
+		eventRule := &v3.EventRule{
+			EventType:    v.Spec.TargetEvent.EventType,
+			ResourceKind: v.Spec.TargetEvent.ResourceKind,
+			MinCount:     1,
+		}
+		if err := validateEventRule(eventRule); err != nil {
+			return errors.Wrap(err, "migrated event rule invalid")
+		}
+		newClusterRule.Spec.EventRule = eventRule
+	}
+
+	if v.Spec.TargetSystemService != nil {
+		newClusterRule.Spec.SystemServiceRule = &v3.SystemServiceRule{
+			Condition: v.Spec.TargetSystemService.Condition,
+		}
+	}
+
+	oldClusterRule, err := l.clusterAlertRules.Get(newClusterRule.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get alert rule failed, %v", err)
+		}
+
+		if _, err = l.clusterAlertRules.Create(newClusterRule); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create alert rule failed, %v", err)
+		}
+	} else {
+		updatedClusterRule := oldClusterRule.DeepCopy()
+		updatedClusterRule.Spec = newClusterRule.Spec
+		if _, err := l.clusterAlertRules.Update(updatedClusterRule); err != nil {
+			return fmt.Errorf("update alert rule failed, %v", err)
+		}
+	}
+	legacyGroup := &v3.ClusterAlertGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationGroupName,
+			Namespace: l.clusterName,
+		},
+		Spec: v3.ClusterGroupSpec{
+			ClusterName: l.clusterName,
+			CommonGroupField: v3.CommonGroupField{
+				DisplayName: "Migrate group",
+				Description: "Migrate alert from last version",
+				TimingField: v3.TimingField{
+					GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
+					GroupIntervalSeconds:  defaultGroupIntervalSeconds,
+					RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
+				},
+			},
+			Recipients: v.Spec.Recipients,
+		},
+	}
+
+	if _, err := l.clusterAlertGroups.Create(legacyGroup); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("create alert group %s:%s failed, %v", l.clusterName, migrationGroupName, err)
+	}
+
+	updatedLegacy := v.DeepCopy()
+	if updatedLegacy.Annotations == nil {
+		updatedLegacy.Annotations = map[string]string{}
+	}
+	updatedLegacy.Annotations[migratedToAnnotation] = newClusterRule.Name
+	if _, err := l.oldClusterAlerts.Update(updatedLegacy); err != nil {
+		return fmt.Errorf("mark %s:%s migrated failed, %v", v.Namespace, v.Name, err)
+	}
+	return nil
+}
+
+func (l *AlertService) migrateLegacyProjectAlert(report *MigrationReport) error {
+	oldProjectAlert, err := l.oldProjectAlerts.List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("get old project alert failed, %s", err)
+	}
+
+	oldProjectAlertGroup := make(map[string][]v3.ProjectAlert)
+	for _, v := range oldProjectAlert.Items {
+		if controller.ObjectInCluster(l.clusterName, v) {
+			oldProjectAlertGroup[v.Spec.ProjectName] = append(oldProjectAlertGroup[v.Spec.ProjectName], v)
+		}
+	}
+
+	for projectID, oldAlerts := range oldProjectAlertGroup {
+		_, projectName := ref.Parse(projectID)
+
+		for _, v := range oldAlerts {
+			if v.Annotations[migratedToAnnotation] != "" {
+				report.skipped(v.Namespace, v.Name)
+				continue
+			}
+
+			if err := l.migrateOneLegacyProjectAlert(projectID, projectName, &v); err != nil {
+				report.failed(v.Namespace, v.Name, err)
+				continue
+			}
+			report.migrated(v.Namespace, v.Name)
+		}
+	}
+	return nil
+}
+
+func (l *AlertService) migrateOneLegacyProjectAlert(projectID, projectName string, v *v3.ProjectAlert) error {
+	migrationGroupName := fmt.Sprintf("migrate-group-%s", v.Name)
+	groupID := alertutil.GetGroupID(projectName, migrationGroupName)
+
+	migrationRuleName := fmt.Sprintf("migrate-rule-%s", v.Name)
+	newProjectRule := &v3.ProjectAlertRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationRuleName,
+			Namespace: projectName,
+		},
+		Spec: v3.ProjectAlertRuleSpec{
+			ProjectName: projectID,
+			GroupName:   groupID,
+			CommonRuleField: v3.CommonRuleField{
+				DisplayName: v.Spec.DisplayName,
+				Severity:    v.Spec.Severity,
+				TimingField: v3.TimingField{
+					GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
+					GroupIntervalSeconds:  defaultGroupIntervalSeconds,
+					RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
+				},
+			},
+		},
+	}
+
+	if v.Spec.TargetPod != nil {
+		// Preserve the legacy absolute-restart-count behavior by default; Mode stays
+		// Cumulative unless an operator opts a migrated rule into the windowed form.
+		newProjectRule.Spec.PodRule = &v3.PodRule{
+			PodName:                v.Spec.TargetPod.PodName,
+			Condition:              v.Spec.TargetPod.Condition,
+			RestartTimes:           v.Spec.TargetPod.RestartTimes,
+			RestartIntervalSeconds: v.Spec.TargetPod.RestartIntervalSeconds,
+			Mode:                   v3.PodRuleModeCumulative,
+			Threshold:              v.Spec.TargetPod.RestartTimes,
+			WindowSeconds:          v.Spec.TargetPod.RestartIntervalSeconds,
+		}
+	}
+
+	if v.Spec.TargetWorkload != nil {
+		newProjectRule.Spec.WorkloadRule = &v3.WorkloadRule{
+			WorkloadID:          v.Spec.TargetWorkload.WorkloadID,
+			Selector:            v.Spec.TargetWorkload.Selector,
+			AvailablePercentage: v.Spec.TargetWorkload.AvailablePercentage,
+		}
+	}
+
+	oldProjectRule, err := l.projectAlertRules.GetNamespaced(projectName, newProjectRule.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get alert rule failed, %v", err)
+		}
+
+		if _, err = l.projectAlertRules.Create(newProjectRule); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create alert rule failed, %v", err)
+		}
+	} else {
+		updatedProjectRule := oldProjectRule.DeepCopy()
+		updatedProjectRule.Spec = newProjectRule.Spec
+		if _, err := l.projectAlertRules.Update(updatedProjectRule); err != nil {
+			return fmt.Errorf("update alert rule failed, %v", err)
+		}
+	}
+
+	legacyGroup := &v3.ProjectAlertGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationGroupName,
+			Namespace: projectName,
+		},
+		Spec: v3.ProjectGroupSpec{
+			ProjectName: projectID,
+			CommonGroupField: v3.CommonGroupField{
+				DisplayName: "Migrate group",
+				Description: "Migrate alert from last version",
+				TimingField: v3.TimingField{
+					GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
+					GroupIntervalSeconds:  defaultGroupIntervalSeconds,
+					RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
+				},
+			},
+			Recipients: v.Spec.Recipients,
+		},
+	}
+
+	if _, err := l.projectAlertGroups.Create(legacyGroup); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("create migrate alert group %s:%s failed, %v", legacyGroup.Namespace, legacyGroup.Name, err)
+	}
+
+	updatedLegacy := v.DeepCopy()
+	if updatedLegacy.Annotations == nil {
+		updatedLegacy.Annotations = map[string]string{}
+	}
+	updatedLegacy.Annotations[migratedToAnnotation] = migrationRuleName
+	if _, err := l.oldProjectAlerts.Update(updatedLegacy); err != nil {
+		return fmt.Errorf("mark %s:%s migrated failed, %v", v.Namespace, v.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package deployer
+
+import (
+	"sync"
+	"time"
+
+	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
+)
+
+// restartSample is one observed restart count for a pod at a point in time, mirroring the
+// pod watcher's restartTrack map but keeping a ring of samples instead of a single count
+// so a sustained-restart PodRule can look back across a trailing window.
+type restartSample struct {
+	count     int64
+	timestamp time.Time
+}
+
+// podRestartTracker evaluates v3.PodRule in either its legacy Cumulative form or the
+// windowed Sustained form, requiring two consecutive breaches of the window before firing
+// so a single noisy scrape doesn't trip the alert. It is exposed through
+// AlertService.EvaluatePodRestart for the pod-restart watcher's per-scrape reconcile loop
+// to call; that watcher itself lives outside this package and is not part of this change.
+type podRestartTracker struct {
+	lock    sync.Mutex
+	samples map[string][]restartSample
+	breach  map[string]int
+}
+
+func newPodRestartTracker() *podRestartTracker {
+	return &podRestartTracker{
+		samples: map[string][]restartSample{},
+		breach:  map[string]int{},
+	}
+}
+
+// Observe reports whether rule should fire for podKey's current restart count. In
+// Sustained mode it records the count as a sample; in Cumulative mode it only compares
+// restartCount with RestartTimes and records nothing.
+func (t *podRestartTracker) Observe(podKey string, restartCount int64, now time.Time, rule *v3.PodRule) bool {
+	if rule.Mode != v3.PodRuleModeSustained {
+		return restartCount >= rule.RestartTimes
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	window := time.Duration(rule.WindowSeconds) * time.Second
+	samples := append(t.samples[podKey], restartSample{count: restartCount, timestamp: now})
+
+	cutoff := now.Add(-window)
+	kept := samples[:0]
+	for _, s := range samples {
+		if !s.timestamp.Before(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	t.samples[podKey] = kept
+
+	restartsWithinWindow := int64(0)
+	if len(kept) > 0 {
+		restartsWithinWindow = kept[len(kept)-1].count - kept[0].count
+	}
+
+	if restartsWithinWindow >= rule.Threshold {
+		t.breach[podKey]++
+	} else {
+		t.breach[podKey] = 0
+	}
+
+	return t.breach[podKey] >= 2
+}
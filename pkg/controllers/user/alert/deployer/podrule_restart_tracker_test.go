@@ -0,0 +1,64 @@
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
+)
+
+func TestPodRestartTrackerCumulativeMode(t *testing.T) {
+	tracker := newPodRestartTracker()
+	rule := &v3.PodRule{Mode: v3.PodRuleModeCumulative, RestartTimes: 3}
+
+	if tracker.Observe("pod-a", 2, time.Now(), rule) {
+		t.Fatal("expected no fire below RestartTimes")
+	}
+	if !tracker.Observe("pod-a", 3, time.Now(), rule) {
+		t.Fatal("expected fire once restartCount reaches RestartTimes")
+	}
+}
+
+func TestPodRestartTrackerSustainedModeRequiresTwoConsecutiveBreaches(t *testing.T) {
+	tracker := newPodRestartTracker()
+	rule := &v3.PodRule{Mode: v3.PodRuleModeSustained, WindowSeconds: 60, Threshold: 2}
+
+	now := time.Now()
+	if tracker.Observe("pod-a", 0, now, rule) {
+		t.Fatal("expected no fire on first sample")
+	}
+	// Within the window, restarts go from 0 to 2: breaches once.
+	if tracker.Observe("pod-a", 2, now.Add(10*time.Second), rule) {
+		t.Fatal("expected no fire on first breach, only the second consecutive one")
+	}
+	if !tracker.Observe("pod-a", 4, now.Add(20*time.Second), rule) {
+		t.Fatal("expected fire on second consecutive breach")
+	}
+}
+
+func TestPodRestartTrackerSustainedModeEvictsOldSamples(t *testing.T) {
+	tracker := newPodRestartTracker()
+	rule := &v3.PodRule{Mode: v3.PodRuleModeSustained, WindowSeconds: 30, Threshold: 2}
+
+	now := time.Now()
+	tracker.Observe("pod-a", 0, now, rule)
+	tracker.Observe("pod-a", 2, now.Add(10*time.Second), rule)
+
+	// This sample falls far outside the 30s window relative to the earlier samples, so
+	// only it should remain once they're evicted, and the delta should reset to 0.
+	if tracker.Observe("pod-a", 2, now.Add(5*time.Minute), rule) {
+		t.Fatal("expected no fire once earlier samples have aged out of the window")
+	}
+}
+
+func TestPodRestartTrackerTracksPodsIndependently(t *testing.T) {
+	tracker := newPodRestartTracker()
+	rule := &v3.PodRule{Mode: v3.PodRuleModeCumulative, RestartTimes: 1}
+
+	if !tracker.Observe("pod-a", 1, time.Now(), rule) {
+		t.Fatal("expected pod-a to fire")
+	}
+	if tracker.Observe("pod-b", 0, time.Now(), rule) {
+		t.Fatal("expected pod-b, with no restarts yet, not to fire")
+	}
+}
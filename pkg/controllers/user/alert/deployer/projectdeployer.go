@@ -0,0 +1,262 @@
+package deployer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rancher/rancher/pkg/controllers/user/helm/common"
+	"github.com/rancher/rancher/pkg/ref"
+	"github.com/rancher/rancher/pkg/settings"
+	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
+	projectv3 "github.com/rancher/types/apis/project.cattle.io/v3"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	projectAlertingEnabledAnnotation = "alerting.cattle.io/enabled"
+	projectRouteScopeAnswerKey       = "alertmanager.projectId"
+	projectAlertAppPrefix            = "alertmanager-"
+)
+
+// projectAlertDeployer is the project-scoped sibling of appDeployer: instead of the single
+// cluster-scoped alertmanager in the system project, it installs a dedicated
+// alertmanager-<projectID> app in the namespace of every Project carrying
+// alerting.cattle.io/enabled=true.
+type projectAlertDeployer struct {
+	apps               projectv3.AppInterface
+	projectLister      v3.ProjectLister
+	templateLister     v3.CatalogTemplateLister
+	projectAlertGroups v3.ProjectAlertGroupLister
+	projectAlertRules  v3.ProjectAlertRuleLister
+}
+
+func newProjectAlertDeployer(l *AlertService) *projectAlertDeployer {
+	return &projectAlertDeployer{
+		apps:               l.apps,
+		projectLister:      l.projectLister,
+		templateLister:     l.templateLister,
+		projectAlertGroups: l.projectAlertGroups.Controller().Lister(),
+		projectAlertRules:  l.projectAlertRules.Controller().Lister(),
+	}
+}
+
+func projectAlertAppName(projectID string) string {
+	_, projectName := ref.Parse(projectID)
+	return fmt.Sprintf("alertmanager-%s", projectName)
+}
+
+// projectAlertAnswers returns the chart answers for project's alertmanager app, scoped so
+// its routes only ever cover alerts belonging to this project: the underlying chart's
+// alertmanager.yml is rendered from every ProjectAlertGroup/ProjectAlertRule it can see, so
+// without projectRouteScopeAnswerKey a project's alertmanager would happily route alerts
+// raised against a different project.
+func (d *projectAlertDeployer) projectAlertAnswers(project *v3.Project) map[string]string {
+	answers := map[string]string{}
+	for k, v := range project.Spec.AlertAnswers {
+		answers[k] = v
+	}
+	answers[projectRouteScopeAnswerKey] = project.Name
+	return answers
+}
+
+// Version resolves the same template version as AlertService.Version, since project
+// alertmanagers are deployed from the same system monitoring catalog entry, then fans out
+// over every project to confirm its alertmanager app (if it should have one) actually
+// converged. It aggregates readiness all-or-first-error, same as Upgrade: the first
+// project whose app isn't installed/deployed yet aborts the rest so AlertService.Version
+// reports not-ready rather than a version neither deployer can actually stand behind.
+func (d *projectAlertDeployer) Version() (string, error) {
+	catalogID := settings.SystemMonitoringCatalogID.Get()
+	templateVersionID, _, err := common.ParseExternalID(catalogID)
+	if err != nil {
+		return "", fmt.Errorf("get system monitor catalog version failed, %v", err)
+	}
+
+	projects, err := d.projectLister.List(metav1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return "", fmt.Errorf("list projects failed, %v", err)
+	}
+
+	for _, project := range projects {
+		if err := d.checkProjectAppReady(project); err != nil {
+			return "", errors.Wrapf(err, "project alertmanager %s", project.Name)
+		}
+	}
+
+	return templateVersionID, nil
+}
+
+// checkProjectAppReady reports whether project's alertmanager app (if alerting.cattle.io/
+// enabled and it has alerts to route) has actually converged, the same criteria syncProject
+// uses to decide whether the app should exist at all.
+func (d *projectAlertDeployer) checkProjectAppReady(project *v3.Project) error {
+	enabled := project.Annotations[projectAlertingEnabledAnnotation] == "true"
+	if enabled {
+		hasAlerts, err := d.hasProjectScopedAlerts(project.Name)
+		if err != nil {
+			return errors.Wrapf(err, "list project alerts for %s", project.Name)
+		}
+		enabled = hasAlerts
+	}
+	if !enabled {
+		return nil
+	}
+
+	appName := projectAlertAppName(project.Name)
+	app, err := d.apps.GetNamespaced(project.Name, appName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get app %s:%s failed, %v", project.Name, appName, err)
+	}
+	if !projectv3.AppConditionInstalled.IsTrue(app) || !projectv3.AppConditionDeployed.IsTrue(app) {
+		return fmt.Errorf("app %s:%s has not converged yet", project.Name, appName)
+	}
+	return nil
+}
+
+// Upgrade fans out over every project in the cluster, installing, updating, or tearing
+// down that project's alertmanager app to match its alerting.cattle.io/enabled
+// annotation. It aggregates readiness all-or-first-error: the first project that fails
+// to converge aborts the rest so a partial cluster-wide state isn't reported as success.
+func (d *projectAlertDeployer) Upgrade(clusterName, currentVersion string) (string, error) {
+	templateVersionNamespace, systemCatalogName, _, templateName, _, err := common.SplitExternalID(settings.SystemMonitoringCatalogID.Get())
+	if err != nil {
+		return "", err
+	}
+
+	templateID := fmt.Sprintf("%s-%s", systemCatalogName, templateName)
+	template, err := d.templateLister.Get(templateVersionNamespace, templateID)
+	if err != nil {
+		return "", errors.Wrapf(err, "get template %s failed", templateID)
+	}
+	newExternalID := fmt.Sprintf("catalog://?catalog=%s&template=%s&version=%s", systemCatalogName, templateName, template.Spec.DefaultVersion)
+
+	newVersion, _, err := common.ParseExternalID(newExternalID)
+	if err != nil {
+		return "", err
+	}
+
+	projects, err := d.projectLister.List(metav1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return "", fmt.Errorf("list projects failed, %v", err)
+	}
+
+	liveProjects := make(map[string]bool, len(projects))
+	for _, project := range projects {
+		liveProjects[project.Name] = true
+		if err := d.syncProject(project, newExternalID); err != nil {
+			return "", errors.Wrapf(err, "sync project alertmanager for %s", project.Name)
+		}
+	}
+
+	if err := d.teardownOrphanedApps(liveProjects); err != nil {
+		return "", errors.Wrap(err, "teardown orphaned project alertmanagers")
+	}
+
+	return newVersion, nil
+}
+
+// teardownOrphanedApps removes alertmanager-<projectID> apps left behind by projects that
+// have since been deleted: a deleted project simply no longer appears in projectLister.List,
+// so syncProject is never called for it and its app would otherwise never be cleaned up.
+func (d *projectAlertDeployer) teardownOrphanedApps(liveProjects map[string]bool) error {
+	apps, err := d.apps.List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list project apps failed, %v", err)
+	}
+
+	for _, app := range apps.Items {
+		if app.Spec.ProjectName != app.Namespace || !strings.HasPrefix(app.Name, projectAlertAppPrefix) {
+			// Not one of this deployer's apps: the cluster-wide alertmanager lives in
+			// the system project under a different name.
+			continue
+		}
+		if liveProjects[app.Namespace] {
+			continue
+		}
+		if err := d.apps.DeleteNamespaced(app.Namespace, app.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete orphaned project app %s:%s failed, %v", app.Namespace, app.Name, err)
+		}
+	}
+	return nil
+}
+
+// hasProjectScopedAlerts reports whether project has any alert group or rule of its own to
+// route. A project with alerting.cattle.io/enabled=true but nothing configured yet would
+// otherwise get an alertmanager with an empty route table, scoped to nothing.
+func (d *projectAlertDeployer) hasProjectScopedAlerts(projectName string) (bool, error) {
+	groups, err := d.projectAlertGroups.List(projectName, labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	if len(groups) > 0 {
+		return true, nil
+	}
+	rules, err := d.projectAlertRules.List(projectName, labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	return len(rules) > 0, nil
+}
+
+func (d *projectAlertDeployer) syncProject(project *v3.Project, externalID string) error {
+	appName := projectAlertAppName(project.Name)
+	enabled := project.Annotations[projectAlertingEnabledAnnotation] == "true"
+	if enabled {
+		hasAlerts, err := d.hasProjectScopedAlerts(project.Name)
+		if err != nil {
+			return errors.Wrapf(err, "list project alerts for %s", project.Name)
+		}
+		enabled = hasAlerts
+	}
+
+	app, err := d.apps.GetNamespaced(project.Name, appName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get app %s:%s failed, %v", project.Name, appName, err)
+		}
+		if !enabled {
+			return nil
+		}
+		_, err := d.apps.Create(&projectv3.App{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      appName,
+				Namespace: project.Name,
+			},
+			Spec: projectv3.AppSpec{
+				ProjectName: project.Name,
+				ExternalID:  externalID,
+				Answers:     d.projectAlertAnswers(project),
+			},
+		})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create project app %s:%s failed, %v", project.Name, appName, err)
+		}
+		return nil
+	}
+
+	if !enabled {
+		// alerting was disabled or the project has nothing to route: tear down its app.
+		// Apps of deleted projects are removed by teardownOrphanedApps instead.
+		if err := d.apps.DeleteNamespaced(project.Name, appName, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete project app %s:%s failed, %v", project.Name, appName, err)
+		}
+		return nil
+	}
+
+	newApp := app.DeepCopy()
+	newApp.Spec.ExternalID = externalID
+	newApp.Spec.Answers = d.projectAlertAnswers(project)
+	if reflect.DeepEqual(newApp, app) {
+		return nil
+	}
+
+	if _, err := d.apps.Update(newApp); err != nil {
+		return fmt.Errorf("update project app %s:%s failed, %v", project.Name, appName, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	monitorutil "github.com/rancher/rancher/pkg/monitoring"
+	projectv3 "github.com/rancher/types/apis/project.cattle.io/v3"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	previousVersionAnnotation = "alerting.cattle.io/previous-version"
+	rollbackSettleWindow      = 10 * time.Minute
+	rollbackSampleInterval    = 30 * time.Second
+	// rollbackMaxFailedSamples is sized to rollbackSettleWindow/rollbackSampleInterval so
+	// it's a sanity backstop, not an earlier trigger: rollbackSettleWindow is the actual
+	// budget a legitimately converging rollout (image pull, scheduling, readiness probes)
+	// gets before it's reverted.
+	rollbackMaxFailedSamples = int(rollbackSettleWindow / rollbackSampleInterval)
+)
+
+// ErrUpgradePending is returned by Version/Upgrade while a previously started upgrade is
+// still being watched for a failed rollout, so the outer deployer loop doesn't try to
+// re-upgrade an app whose rollback may still be in flight.
+var ErrUpgradePending = errors.New("alerting app upgrade pending rollback evaluation")
+
+// previousAppSpec is what gets recorded in previousVersionAnnotation before an upgrade, so
+// a failed rollout can be reverted without refetching the old app.
+type previousAppSpec struct {
+	ExternalID string            `json:"externalId"`
+	Answers    map[string]string `json:"answers"`
+}
+
+// rollbackTracker records whether the alertmanager app is currently mid-upgrade and being
+// watched for a failed rollout.
+type rollbackTracker struct {
+	lock    sync.Mutex
+	pending bool
+}
+
+func (t *rollbackTracker) start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pending = true
+}
+
+func (t *rollbackTracker) finish() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pending = false
+}
+
+func (t *rollbackTracker) isPending() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.pending
+}
+
+// upgradeWithRollback records newApp's predecessor spec in an annotation, pushes the
+// update, and starts a background reconciler that watches the rollout and restores the
+// previous spec if it never settles.
+func (l *AlertService) upgradeWithRollback(app, newApp *projectv3.App) error {
+	previous := previousAppSpec{
+		ExternalID: app.Spec.ExternalID,
+		Answers:    app.Spec.Answers,
+	}
+	previousJSON, err := json.Marshal(previous)
+	if err != nil {
+		return errors.Wrap(err, "marshal previous app spec for rollback")
+	}
+	if newApp.Annotations == nil {
+		newApp.Annotations = map[string]string{}
+	}
+	newApp.Annotations[previousVersionAnnotation] = string(previousJSON)
+
+	updated, err := l.apps.Update(newApp)
+	if err != nil {
+		return fmt.Errorf("update app %s:%s failed, %v", newApp.Namespace, newApp.Name, err)
+	}
+
+	l.rollback.start()
+	go l.watchRollout(updated, updated.Generation, previous)
+	return nil
+}
+
+// watchRollout polls the app's install/deploy conditions and the alertmanager
+// StatefulSet's ready-replica count for rollbackSettleWindow. expectedGeneration is the
+// app's Generation right after the triggering Update, so an early sample can't mistake
+// conditions and a StatefulSet revision left over from before this upgrade for a healthy
+// rollout. If it keeps failing across rollbackMaxFailedSamples consecutive samples, it
+// restores the previous spec and emits a diagnostic Event on the app.
+func (l *AlertService) watchRollout(app *projectv3.App, expectedGeneration int64, previous previousAppSpec) {
+	defer l.rollback.finish()
+
+	_, statefulSetName := monitorutil.ClusterAlertManagerInfo()
+	deadline := time.Now().Add(rollbackSettleWindow)
+	failedSamples := 0
+
+	ticker := time.NewTicker(rollbackSampleInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		current, err := l.apps.GetNamespaced(app.Namespace, app.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return
+			}
+			continue
+		}
+
+		if l.rolloutHealthy(current, statefulSetName, expectedGeneration) {
+			return
+		}
+		failedSamples++
+
+		if failedSamples >= rollbackMaxFailedSamples {
+			l.restorePreviousSpec(current, previous)
+			return
+		}
+
+		if now.After(deadline) {
+			l.restorePreviousSpec(current, previous)
+			return
+		}
+	}
+}
+
+func (l *AlertService) rolloutHealthy(app *projectv3.App, statefulSetName string, expectedGeneration int64) bool {
+	if app.Status.ObservedGeneration < expectedGeneration {
+		// The app controller hasn't even looked at this upgrade yet: whatever the
+		// conditions currently say describes the previous rollout, not this one.
+		return false
+	}
+	if projectv3.AppConditionInstalled.IsFalse(app) || projectv3.AppConditionDeployed.IsFalse(app) {
+		return false
+	}
+
+	statefulSet, err := l.statefulSets.GetNamespaced(app.Namespace, statefulSetName, metav1.GetOptions{})
+	if err != nil {
+		// A chart that never even created the workload is not a healthy rollout; feed
+		// it into the same failedSamples/deadline logic as any other unready state.
+		return false
+	}
+	if statefulSet.Spec.Replicas != nil && statefulSet.Status.ReadyReplicas < *statefulSet.Spec.Replicas {
+		return false
+	}
+	if statefulSet.Status.CurrentRevision != statefulSet.Status.UpdateRevision {
+		// Pods from the previous rollout are still what's ready; this one hasn't rolled
+		// out yet.
+		return false
+	}
+	return true
+}
+
+func (l *AlertService) restorePreviousSpec(app *projectv3.App, previous previousAppSpec) {
+	reverted := app.DeepCopy()
+	reverted.Spec.ExternalID = previous.ExternalID
+	reverted.Spec.Answers = previous.Answers
+	delete(reverted.Annotations, previousVersionAnnotation)
+
+	if _, err := l.apps.Update(reverted); err != nil {
+		logrus.Errorf("alerting: failed to revert app %s:%s after a failed rollout: %v", app.Namespace, app.Name, err)
+		l.recordRollbackEvent(app, fmt.Sprintf("alertmanager rollout for %s did not become healthy within %s and the automatic rollback to %s also failed: %v", app.Name, rollbackSettleWindow, previous.ExternalID, err))
+		return
+	}
+
+	l.recordRollbackEvent(app, fmt.Sprintf("alertmanager rollout for %s did not become healthy within %s, reverted to %s", app.Name, rollbackSettleWindow, previous.ExternalID))
+}
+
+func (l *AlertService) recordRollbackEvent(app *projectv3.App, reason string) {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-rollback-", app.Name),
+			Namespace:    app.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "App",
+			Namespace: app.Namespace,
+			Name:      app.Name,
+			UID:       app.UID,
+		},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "AlertingUpgradeRolledBack",
+		Message:        reason,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	l.events.Create(event)
+}
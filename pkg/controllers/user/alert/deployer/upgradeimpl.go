@@ -6,19 +6,18 @@ import (
 	"strings"
 	"time"
 
-	"github.com/rancher/norman/controller"
-	alertutil "github.com/rancher/rancher/pkg/controllers/user/alert/common"
 	"github.com/rancher/rancher/pkg/controllers/user/helm/common"
 	monitorutil "github.com/rancher/rancher/pkg/monitoring"
 	"github.com/rancher/rancher/pkg/namespace"
-	"github.com/rancher/rancher/pkg/ref"
 	"github.com/rancher/rancher/pkg/settings"
+	appsv1beta2 "github.com/rancher/types/apis/apps/v1beta2"
 	v1 "github.com/rancher/types/apis/core/v1"
 	v3 "github.com/rancher/types/apis/management.cattle.io/v3"
 	projectv3 "github.com/rancher/types/apis/project.cattle.io/v3"
 	"github.com/rancher/types/config"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -31,6 +30,7 @@ var (
 
 const (
 	defaultGroupIntervalSeconds = 180
+	legacyAlertmanagerNamespace = "cattle-alerting"
 )
 
 type AlertService struct {
@@ -48,10 +48,40 @@ type AlertService struct {
 	namespaces         v1.NamespaceInterface
 	templateLister     v3.CatalogTemplateLister
 	appDeployer        *appDeployer
+	projectDeployer    *projectAlertDeployer
+	statefulSets       appsv1beta2.StatefulSetInterface
+	events             v1.EventInterface
+	configMaps         v1.ConfigMapInterface
+	rollback           *rollbackTracker
+	podRestarts        *podRestartTracker
+	eventRules         *eventRuleTracker
 }
 
 func NewService() *AlertService {
-	return &AlertService{}
+	return &AlertService{
+		rollback:    &rollbackTracker{},
+		podRestarts: newPodRestartTracker(),
+		eventRules:  newEventRuleTracker(),
+	}
+}
+
+// EvaluatePodRestart reports whether rule should fire for podKey's restartCount observed
+// at now, in either its Cumulative or windowed Sustained mode. The pod-restart watcher's
+// reconcile loop calls this once per scrape per pod.
+func (l *AlertService) EvaluatePodRestart(podKey string, restartCount int64, now time.Time, rule *v3.PodRule) bool {
+	return l.podRestarts.Observe(podKey, restartCount, now, rule)
+}
+
+// EvaluateEventRule reports whether rule should fire for event, given involvedObjectLabels
+// already resolved by the caller from event.InvolvedObject. ruleKey scopes the occurrence
+// count to a single rule instance, the same way podKey scopes EvaluatePodRestart. An
+// event-watcher reconcile loop calls this once per scraped event; that watcher itself lives
+// outside this package and is not part of this change.
+func (l *AlertService) EvaluateEventRule(ruleKey string, event *corev1.Event, involvedObjectLabels map[string]string, now time.Time, rule *v3.EventRule) bool {
+	if !matchesEventRule(event, involvedObjectLabels, rule) {
+		return false
+	}
+	return l.eventRules.Observe(ruleKey, now, rule)
 }
 
 func (l *AlertService) Init(cluster *config.UserContext) {
@@ -76,19 +106,38 @@ func (l *AlertService) Init(cluster *config.UserContext) {
 	l.namespaces = cluster.Core.Namespaces(metav1.NamespaceAll)
 	l.templateLister = cluster.Management.Management.CatalogTemplates(metav1.NamespaceAll).Controller().Lister()
 	l.appDeployer = ad
+	l.statefulSets = cluster.Apps.StatefulSets(metav1.NamespaceAll)
+	l.events = cluster.Core.Events(metav1.NamespaceAll)
+	l.configMaps = cluster.Core.ConfigMaps(metav1.NamespaceAll)
+	l.projectDeployer = newProjectAlertDeployer(l)
 
 }
 
 func (l *AlertService) Version() (string, error) {
+	if l.rollback.isPending() {
+		return "", ErrUpgradePending
+	}
+
 	catalogID := settings.SystemMonitoringCatalogID.Get()
 	templateVersionID, _, err := common.ParseExternalID(catalogID)
 	if err != nil {
 		return "", fmt.Errorf("get system monitor catalog version failed, %v", err)
 	}
+
+	// Project alertmanagers are deployed from the same catalog entry, so only the project
+	// deployer's readiness error is used here; the version it returns is discarded.
+	if _, err := l.projectDeployer.Version(); err != nil {
+		return "", errors.Wrap(err, "get project alertmanager version")
+	}
+
 	return templateVersionID, nil
 }
 
 func (l *AlertService) Upgrade(currentVersion string) (string, error) {
+	if l.rollback.isPending() {
+		return "", ErrUpgradePending
+	}
+
 	templateVersionNamespace, systemCatalogName, _, templateName, _, err := common.SplitExternalID(settings.SystemMonitoringCatalogID.Get())
 	if err != nil {
 		return "", err
@@ -107,19 +156,39 @@ func (l *AlertService) Upgrade(currentVersion string) (string, error) {
 	}
 
 	appName, _ := monitorutil.ClusterAlertManagerInfo()
+	// resultVersion is what Upgrade reports back to the caller. It starts optimistic and
+	// is pinned back to currentVersion below if legacy migration left anything pending,
+	// so the reconcile is retried instead of being reported as fully upgraded.
+	resultVersion := newVersion
+
 	//migrate legacy
 	if !strings.Contains(currentVersion, "system-library-rancher-monitoring") {
-		if err := l.migrateLegacyClusterAlert(); err != nil {
+		report := &MigrationReport{}
+
+		if err := l.migrateLegacyClusterAlert(report); err != nil {
 			return "", err
 		}
 
-		if err := l.migrateLegacyProjectAlert(); err != nil {
+		if err := l.migrateLegacyProjectAlert(report); err != nil {
 			return "", err
 		}
 
-		if err := l.removeLegacyAlerting(); err != nil {
+		if err := l.persistReport(report); err != nil {
 			return "", err
 		}
+
+		// A broken legacy rule shouldn't block the chart upgrade or project sync below
+		// forever (every alert the report marked Failed is left without the migrated-to
+		// annotation and retried on the next reconcile), but Upgrade must not report
+		// itself fully on newVersion until
+		// the report shows zero pending items.
+		if report.Pending() == 0 {
+			if err := l.removeLegacyAlerting(); err != nil {
+				return "", err
+			}
+		} else {
+			resultVersion = currentVersion
+		}
 	}
 
 	//upgrade old app
@@ -139,7 +208,7 @@ func (l *AlertService) Upgrade(currentVersion string) (string, error) {
 	app, err := l.apps.GetNamespaced(systemProject.Name, appName, metav1.GetOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			return newVersion, nil
+			return resultVersion, nil
 		}
 		return "", fmt.Errorf("get app %s:%s failed, %v", systemProject.Name, appName, err)
 	}
@@ -166,217 +235,19 @@ func (l *AlertService) Upgrade(currentVersion string) (string, error) {
 			return "", fmt.Errorf("catalog %v not ready", systemCatalogName)
 		}
 
-		if _, err = l.apps.Update(newApp); err != nil {
-			return "", fmt.Errorf("update app %s:%s failed, %v", app.Namespace, app.Name, err)
-		}
-	}
-	return newVersion, nil
-}
-
-func (l *AlertService) migrateLegacyClusterAlert() error {
-	oldClusterAlert, err := l.oldClusterAlerts.List(metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("get old cluster alert failed, %s", err)
-	}
-	for _, v := range oldClusterAlert.Items {
-		migrationGroupName := fmt.Sprintf("migrate-group-%s", v.Name)
-		groupID := alertutil.GetGroupID(l.clusterName, migrationGroupName)
-
-		name := fmt.Sprintf("migrate-%s", v.Name)
-		newClusterRule := &v3.ClusterAlertRule{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      name,
-				Namespace: l.clusterName,
-			},
-			Spec: v3.ClusterAlertRuleSpec{
-				ClusterName: l.clusterName,
-				GroupName:   groupID,
-				CommonRuleField: v3.CommonRuleField{
-					DisplayName: v.Spec.DisplayName,
-					Severity:    v.Spec.Severity,
-					TimingField: v3.TimingField{
-						GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
-						GroupIntervalSeconds:  defaultGroupIntervalSeconds,
-						RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
-					},
-				},
-			},
-		}
-
-		if v.Spec.TargetNode != nil {
-			newClusterRule.Spec.NodeRule = &v3.NodeRule{
-				NodeName:     v.Spec.TargetNode.NodeName,
-				Selector:     v.Spec.TargetNode.Selector,
-				Condition:    v.Spec.TargetNode.Condition,
-				MemThreshold: v.Spec.TargetNode.MemThreshold,
-				CPUThreshold: v.Spec.TargetNode.CPUThreshold,
-			}
-		}
-
-		if v.Spec.TargetEvent != nil {
-			newClusterRule.Spec.EventRule = &v3.EventRule{
-				EventType:    v.Spec.TargetEvent.EventType,
-				ResourceKind: v.Spec.TargetEvent.ResourceKind,
-			}
-		}
-
-		if v.Spec.TargetSystemService != nil {
-			newClusterRule.Spec.SystemServiceRule = &v3.SystemServiceRule{
-				Condition: v.Spec.TargetSystemService.Condition,
-			}
-		}
-
-		oldClusterRule, err := l.clusterAlertRules.Get(newClusterRule.Name, metav1.GetOptions{})
-		if err != nil {
-			if !apierrors.IsNotFound(err) {
-				return fmt.Errorf("migrate %s:%s failed, get alert rule failed, %v", v.Namespace, v.Name, err)
-			}
-
-			if _, err = l.clusterAlertRules.Create(newClusterRule); err != nil && !apierrors.IsAlreadyExists(err) {
-				return fmt.Errorf("migrate %s:%s failed, create alert rule failed, %v", v.Namespace, v.Name, err)
-			}
-		} else {
-			updatedClusterRule := oldClusterRule.DeepCopy()
-			updatedClusterRule.Spec = newClusterRule.Spec
-			if _, err := l.clusterAlertRules.Update(updatedClusterRule); err != nil {
-				return fmt.Errorf("migrate %s:%s failed, update alert rule failed, %v", v.Namespace, v.Name, err)
-			}
-		}
-		legacyGroup := &v3.ClusterAlertGroup{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      migrationGroupName,
-				Namespace: l.clusterName,
-			},
-			Spec: v3.ClusterGroupSpec{
-				ClusterName: l.clusterName,
-				CommonGroupField: v3.CommonGroupField{
-					DisplayName: "Migrate group",
-					Description: "Migrate alert from last version",
-					TimingField: v3.TimingField{
-						GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
-						GroupIntervalSeconds:  defaultGroupIntervalSeconds,
-						RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
-					},
-				},
-				Recipients: v.Spec.Recipients,
-			},
-		}
-
-		_, err = l.clusterAlertGroups.Create(legacyGroup)
-		if err != nil && !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("migrate failed, create alert group %s:%s failed, %v", l.clusterName, migrationGroupName, err)
+		if err := l.upgradeWithRollback(app, newApp); err != nil {
+			return "", err
 		}
 	}
-	return nil
-}
 
-func (l *AlertService) migrateLegacyProjectAlert() error {
-	oldProjectAlert, err := l.oldProjectAlerts.List(metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("get old project alert failed, %s", err)
+	if _, err := l.projectDeployer.Upgrade(l.clusterName, currentVersion); err != nil {
+		return "", errors.Wrap(err, "sync project alertmanagers")
 	}
 
-	oldProjectAlertGroup := make(map[string][]v3.ProjectAlert)
-	for _, v := range oldProjectAlert.Items {
-		if controller.ObjectInCluster(l.clusterName, v) {
-			oldProjectAlertGroup[v.Spec.ProjectName] = append(oldProjectAlertGroup[v.Spec.ProjectName], v)
-		}
-	}
-
-	for projectID, oldAlerts := range oldProjectAlertGroup {
-		_, projectName := ref.Parse(projectID)
-
-		for _, v := range oldAlerts {
-			migrationGroupName := fmt.Sprintf("migrate-group-%s", v.Name)
-			groupID := alertutil.GetGroupID(projectName, migrationGroupName)
-
-			migrationRuleName := fmt.Sprintf("migrate-rule-%s", v.Name)
-			newProjectRule := &v3.ProjectAlertRule{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      migrationRuleName,
-					Namespace: projectName,
-				},
-				Spec: v3.ProjectAlertRuleSpec{
-					ProjectName: projectID,
-					GroupName:   groupID,
-					CommonRuleField: v3.CommonRuleField{
-						DisplayName: v.Spec.DisplayName,
-						Severity:    v.Spec.Severity,
-						TimingField: v3.TimingField{
-							GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
-							GroupIntervalSeconds:  defaultGroupIntervalSeconds,
-							RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
-						},
-					},
-				},
-			}
-
-			if v.Spec.TargetPod != nil {
-				newProjectRule.Spec.PodRule = &v3.PodRule{
-					PodName:                v.Spec.TargetPod.PodName,
-					Condition:              v.Spec.TargetPod.Condition,
-					RestartTimes:           v.Spec.TargetPod.RestartTimes,
-					RestartIntervalSeconds: v.Spec.TargetPod.RestartIntervalSeconds,
-				}
-			}
-
-			if v.Spec.TargetWorkload != nil {
-				newProjectRule.Spec.WorkloadRule = &v3.WorkloadRule{
-					WorkloadID:          v.Spec.TargetWorkload.WorkloadID,
-					Selector:            v.Spec.TargetWorkload.Selector,
-					AvailablePercentage: v.Spec.TargetWorkload.AvailablePercentage,
-				}
-			}
-
-			oldProjectRule, err := l.projectAlertRules.GetNamespaced(projectName, newProjectRule.Name, metav1.GetOptions{})
-			if err != nil {
-				if !apierrors.IsNotFound(err) {
-					return fmt.Errorf("migrate %s:%s failed, get alert rule failed, %v", v.Namespace, v.Name, err)
-				}
-
-				if _, err = l.projectAlertRules.Create(newProjectRule); err != nil && !apierrors.IsAlreadyExists(err) {
-					return fmt.Errorf("migrate %s:%s failed, create alert rule failed, %v", v.Namespace, v.Name, err)
-				}
-			} else {
-				updatedProjectRule := oldProjectRule.DeepCopy()
-				updatedProjectRule.Spec = newProjectRule.Spec
-				if _, err := l.projectAlertRules.Update(updatedProjectRule); err != nil {
-					return fmt.Errorf("migrate %s:%s failed, update alert rule failed, %v", v.Namespace, v.Name, err)
-				}
-			}
-
-			legacyGroup := &v3.ProjectAlertGroup{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      migrationGroupName,
-					Namespace: projectName,
-				},
-				Spec: v3.ProjectGroupSpec{
-					ProjectName: projectID,
-					CommonGroupField: v3.CommonGroupField{
-						DisplayName: "Migrate group",
-						Description: "Migrate alert from last version",
-						TimingField: v3.TimingField{
-							GroupWaitSeconds:      v.Spec.InitialWaitSeconds,
-							GroupIntervalSeconds:  defaultGroupIntervalSeconds,
-							RepeatIntervalSeconds: v.Spec.RepeatIntervalSeconds,
-						},
-					},
-					Recipients: v.Spec.Recipients,
-				},
-			}
-
-			legacyGroup, err = l.projectAlertGroups.Create(legacyGroup)
-			if err != nil && !apierrors.IsAlreadyExists(err) {
-				return fmt.Errorf("create migrate alert group %s:%s failed, %v", legacyGroup.Namespace, legacyGroup.Name, err)
-			}
-		}
-	}
-	return nil
+	return resultVersion, nil
 }
 
 func (l *AlertService) removeLegacyAlerting() error {
-	legacyAlertmanagerNamespace := "cattle-alerting"
-
 	if err := l.namespaces.Delete(legacyAlertmanagerNamespace, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
 		return errors.Wrap(err, "failed to remove legacy alerting namespace when upgrade")
 	}
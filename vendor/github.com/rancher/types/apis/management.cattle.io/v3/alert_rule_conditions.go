@@ -0,0 +1,63 @@
+package v3
+
+// This file is the single home for the ClusterAlertRule/ProjectAlertRule condition
+// sub-types (NodeRule, EventRule, SystemServiceRule, PodRule, WorkloadRule). They were
+// previously referenced by the legacy-alert migration but never declared anywhere in this
+// tree; keeping them together here means there is exactly one declaration of each to
+// extend, instead of new files redeclaring types that migration.go already builds.
+
+type NodeRule struct {
+	NodeName     string            `json:"nodeName,omitempty"`
+	Selector     map[string]string `json:"selector,omitempty"`
+	Condition    string            `json:"condition,omitempty" norman:"required,options=notready|mem|cpu"`
+	MemThreshold int64             `json:"memThreshold,omitempty"`
+	CPUThreshold int64             `json:"cpuThreshold,omitempty"`
+}
+
+type SystemServiceRule struct {
+	Condition string `json:"condition,omitempty" norman:"required"`
+}
+
+// EventObjectSelector scopes an EventRule to events whose involved object lives in a
+// given namespace and carries the given labels.
+type EventObjectSelector struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Selector  map[string]string `json:"selector,omitempty"`
+}
+
+type EventRule struct {
+	EventType              string               `json:"eventType,omitempty" norman:"required,options=Normal|Warning"`
+	ResourceKind           string               `json:"resourceKind,omitempty" norman:"required"`
+	ReasonRegex            string               `json:"reasonRegex,omitempty"`
+	MessageRegex           string               `json:"messageRegex,omitempty"`
+	InvolvedObjectSelector *EventObjectSelector `json:"involvedObjectSelector,omitempty"`
+	MinCount               int64                `json:"minCount,omitempty" norman:"default=1"`
+	WindowSeconds          int64                `json:"windowSeconds,omitempty"`
+	SourceComponent        string               `json:"sourceComponent,omitempty"`
+}
+
+type WorkloadRule struct {
+	WorkloadID          string            `json:"workloadId,omitempty" norman:"required"`
+	Selector            map[string]string `json:"selector,omitempty"`
+	AvailablePercentage int64             `json:"availablePercentage,omitempty"`
+}
+
+// PodRuleMode selects how PodRule evaluates restarts: Cumulative fires once the absolute
+// restart count on the pod crosses RestartTimes (the original behavior); Sustained fires
+// only once restarts keep happening within a trailing window.
+type PodRuleMode string
+
+const (
+	PodRuleModeCumulative PodRuleMode = "Cumulative"
+	PodRuleModeSustained  PodRuleMode = "Sustained"
+)
+
+type PodRule struct {
+	PodName                string      `json:"podName,omitempty" norman:"required"`
+	Condition              string      `json:"condition,omitempty" norman:"required,options=notrunning|notscheduled|restarts"`
+	RestartTimes           int64       `json:"restartTimes,omitempty"`
+	RestartIntervalSeconds int64       `json:"restartIntervalSeconds,omitempty"`
+	Mode                   PodRuleMode `json:"mode,omitempty" norman:"default=Cumulative"`
+	WindowSeconds          int64       `json:"windowSeconds,omitempty"`
+	Threshold              int64       `json:"threshold,omitempty"`
+}
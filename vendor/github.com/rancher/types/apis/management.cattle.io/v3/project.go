@@ -0,0 +1,27 @@
+package v3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Project is referenced throughout this tree via ProjectLister/*Project but, like the
+// other generated clients this mirror is missing, was never declared here. This is the
+// minimal shape the alert deployer needs: enough of ObjectMeta to read annotations and a
+// typed ProjectSpec to carry project-scoped configuration that used to be annotation-only.
+type Project struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProjectSpec `json:"spec,omitempty"`
+}
+
+type ProjectSpec struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// AlertAnswers holds the chart answers used to configure this project's alertmanager
+	// app. It replaces the alerting.cattle.io/answers annotation: answers are structured
+	// configuration, not metadata, so they belong in the spec where the API can validate
+	// and default them.
+	AlertAnswers map[string]string `json:"alertAnswers,omitempty"`
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+
+	"github.com/rancher/norman/types"
+)
+
+// ReplicationControllerIterator walks every ReplicationController matching a ListAll
+// query one page at a time: Next fetches the next page only once the current one is
+// exhausted, so a caller holding an iterator never has more than one
+// ReplicationControllerCollection page resident at a time.
+type ReplicationControllerIterator interface {
+	Next(ctx context.Context) bool
+	Value() *ReplicationController
+	Err() error
+}
+
+type replicationControllerIterator struct {
+	client *ReplicationControllerClient
+	opts   *types.ListOpts
+	page   *ReplicationControllerCollection
+	index  int
+	err    error
+}
+
+// ListAll returns an iterator over every ReplicationController matching opts, hiding the
+// cc.Pagination.Next bookkeeping a caller would otherwise have to loop over by hand.
+func (c *ReplicationControllerClient) ListAll(opts *types.ListOpts) ReplicationControllerIterator {
+	return &replicationControllerIterator{
+		client: c,
+		opts:   opts,
+		index:  -1,
+	}
+}
+
+func (it *replicationControllerIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.index++
+	if it.page != nil && it.index < len(it.page.Data) {
+		return true
+	}
+
+	var next *ReplicationControllerCollection
+	var err error
+	if it.page == nil {
+		next, err = it.client.ListContext(ctx, it.opts)
+	} else if it.page.Pagination != nil && it.page.Pagination.Next != "" {
+		next, err = it.page.NextContext(ctx)
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if next == nil || len(next.Data) == 0 {
+		return false
+	}
+
+	it.page = next
+	it.index = 0
+	return true
+}
+
+func (it *replicationControllerIterator) Value() *ReplicationController {
+	if it.page == nil || it.index < 0 || it.index >= len(it.page.Data) {
+		return nil
+	}
+	return &it.page.Data[it.index]
+}
+
+func (it *replicationControllerIterator) Err() error {
+	return it.err
+}
+
+// ForEach walks every ReplicationController matching opts, invoking fn for each one and
+// stopping at the first error it returns.
+func (c *ReplicationControllerClient) ForEach(ctx context.Context, opts *types.ListOpts, fn func(*ReplicationController) error) error {
+	it := c.ListAll(opts)
+	for it.Next(ctx) {
+		if err := fn(it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/norman/types"
+)
+
+func TestReplicationControllerIteratorWalksWithinPage(t *testing.T) {
+	it := &replicationControllerIterator{
+		index: -1,
+		page: &ReplicationControllerCollection{
+			Data: []ReplicationController{{Resource: types.Resource{ID: "1"}}, {Resource: types.Resource{ID: "2"}}},
+		},
+	}
+
+	ctx := context.Background()
+	if !it.Next(ctx) || it.Value().ID != "1" {
+		t.Fatal("expected first call to Next to land on the first element")
+	}
+	if !it.Next(ctx) || it.Value().ID != "2" {
+		t.Fatal("expected second call to Next to land on the second element")
+	}
+}
+
+func TestReplicationControllerIteratorStopsAtEndOfLastPage(t *testing.T) {
+	it := &replicationControllerIterator{
+		index: -1,
+		page: &ReplicationControllerCollection{
+			Data: []ReplicationController{{Resource: types.Resource{ID: "1"}}},
+		},
+	}
+
+	ctx := context.Background()
+	if !it.Next(ctx) {
+		t.Fatal("expected Next to return true for the only element")
+	}
+	// Pagination is nil, so there is no next page to fetch: Next must stop here rather
+	// than attempting a network call.
+	if it.Next(ctx) {
+		t.Fatal("expected Next to return false once the last page is exhausted")
+	}
+	if it.Err() != nil {
+		t.Fatalf("expected no error, got %v", it.Err())
+	}
+}
+
+func TestReplicationControllerIteratorValueOutOfRange(t *testing.T) {
+	it := &replicationControllerIterator{index: -1}
+	if v := it.Value(); v != nil {
+		t.Fatalf("expected nil Value before the first page is loaded, got %v", v)
+	}
+}
+
+func TestReplicationControllerIteratorStopsOnError(t *testing.T) {
+	it := &replicationControllerIterator{index: -1, err: context.Canceled}
+	if it.Next(context.Background()) {
+		t.Fatal("expected Next to return false once err is set")
+	}
+}
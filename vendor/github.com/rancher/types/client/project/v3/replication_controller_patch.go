@@ -0,0 +1,32 @@
+package client
+
+import (
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// Patch issues a PATCH request against ReplicationController using either a JSON Merge
+// Patch or a JSON Patch body, depending on patchType. Unlike Update, which sends a full
+// PUT-ish body, this only transmits the requested change, avoiding optimistic-concurrency
+// retries on high-frequency controller writes.
+func (c *ReplicationControllerClient) Patch(existing *ReplicationController, patchType k8stypes.PatchType, patch []byte) (*ReplicationController, error) {
+	resp := &ReplicationController{}
+	err := c.apiClient.Ops.DoPatch(ReplicationControllerType, &existing.Resource, patchType, patch, resp)
+	return resp, err
+}
+
+// BuildMergePatch diffs orig and modified into a JSON Merge Patch body suitable for Patch
+// with k8stypes.MergePatchType.
+func BuildMergePatch(orig, modified *ReplicationController) ([]byte, error) {
+	origJSON, err := json.Marshal(orig)
+	if err != nil {
+		return nil, err
+	}
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.CreateMergePatch(origJSON, modifiedJSON)
+}
@@ -0,0 +1,30 @@
+package client
+
+const replicationControllerScaleAction = "scale"
+
+// ReplicationControllerScale is the body of the /scale subresource action, a merge
+// patch that touches only the replica count so concurrent updates to containers,
+// labels, etc. on the same ReplicationController are not clobbered.
+type ReplicationControllerScale struct {
+	Scale int64 `json:"scale"`
+}
+
+// GetScale returns the current replica count via the /scale subresource rather than
+// fetching (and racing on) the whole ReplicationController.
+func (c *ReplicationControllerClient) GetScale(existing *ReplicationController) (*int64, error) {
+	resp := &ReplicationControllerScale{}
+	err := c.apiClient.Ops.DoAction(ReplicationControllerType, replicationControllerScaleAction, &existing.Resource, nil, resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Scale, nil
+}
+
+// Scale PATCHes only the scale field through the /scale action endpoint, instead of
+// sending a full Update with the entire object.
+func (c *ReplicationControllerClient) Scale(existing *ReplicationController, replicas int64) (*ReplicationController, error) {
+	input := &ReplicationControllerScale{Scale: replicas}
+	resp := &ReplicationController{}
+	err := c.apiClient.Ops.DoAction(ReplicationControllerType, replicationControllerScaleAction, &existing.Resource, input, resp)
+	return resp, err
+}
@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
+
 	"github.com/rancher/norman/types"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 )
 
 const (
@@ -120,11 +123,27 @@ type ReplicationControllerClient struct {
 
 type ReplicationControllerOperations interface {
 	List(opts *types.ListOpts) (*ReplicationControllerCollection, error)
+	ListContext(ctx context.Context, opts *types.ListOpts) (*ReplicationControllerCollection, error)
 	Create(opts *ReplicationController) (*ReplicationController, error)
+	CreateContext(ctx context.Context, opts *ReplicationController) (*ReplicationController, error)
 	Update(existing *ReplicationController, updates interface{}) (*ReplicationController, error)
+	UpdateContext(ctx context.Context, existing *ReplicationController, updates interface{}) (*ReplicationController, error)
 	Replace(existing *ReplicationController) (*ReplicationController, error)
+	ReplaceContext(ctx context.Context, existing *ReplicationController) (*ReplicationController, error)
 	ByID(id string) (*ReplicationController, error)
+	ByIDContext(ctx context.Context, id string) (*ReplicationController, error)
 	Delete(container *ReplicationController) error
+	DeleteContext(ctx context.Context, container *ReplicationController) error
+
+	Watch(opts *types.ListOpts) (ReplicationControllerWatcher, error)
+
+	GetScale(existing *ReplicationController) (*int64, error)
+	Scale(existing *ReplicationController, replicas int64) (*ReplicationController, error)
+
+	Patch(existing *ReplicationController, patchType k8stypes.PatchType, patch []byte) (*ReplicationController, error)
+
+	ListAll(opts *types.ListOpts) ReplicationControllerIterator
+	ForEach(ctx context.Context, opts *types.ListOpts, fn func(*ReplicationController) error) error
 }
 
 func newReplicationControllerClient(apiClient *Client) *ReplicationControllerClient {
@@ -134,34 +153,54 @@ func newReplicationControllerClient(apiClient *Client) *ReplicationControllerCli
 }
 
 func (c *ReplicationControllerClient) Create(container *ReplicationController) (*ReplicationController, error) {
+	return c.CreateContext(context.Background(), container)
+}
+
+func (c *ReplicationControllerClient) CreateContext(ctx context.Context, container *ReplicationController) (*ReplicationController, error) {
 	resp := &ReplicationController{}
-	err := c.apiClient.Ops.DoCreate(ReplicationControllerType, container, resp)
+	err := c.apiClient.Ops.DoCreateContext(ctx, ReplicationControllerType, container, resp)
 	return resp, err
 }
 
 func (c *ReplicationControllerClient) Update(existing *ReplicationController, updates interface{}) (*ReplicationController, error) {
+	return c.UpdateContext(context.Background(), existing, updates)
+}
+
+func (c *ReplicationControllerClient) UpdateContext(ctx context.Context, existing *ReplicationController, updates interface{}) (*ReplicationController, error) {
 	resp := &ReplicationController{}
-	err := c.apiClient.Ops.DoUpdate(ReplicationControllerType, &existing.Resource, updates, resp)
+	err := c.apiClient.Ops.DoUpdateContext(ctx, ReplicationControllerType, &existing.Resource, updates, resp)
 	return resp, err
 }
 
 func (c *ReplicationControllerClient) Replace(obj *ReplicationController) (*ReplicationController, error) {
+	return c.ReplaceContext(context.Background(), obj)
+}
+
+func (c *ReplicationControllerClient) ReplaceContext(ctx context.Context, obj *ReplicationController) (*ReplicationController, error) {
 	resp := &ReplicationController{}
-	err := c.apiClient.Ops.DoReplace(ReplicationControllerType, &obj.Resource, obj, resp)
+	err := c.apiClient.Ops.DoReplaceContext(ctx, ReplicationControllerType, &obj.Resource, obj, resp)
 	return resp, err
 }
 
 func (c *ReplicationControllerClient) List(opts *types.ListOpts) (*ReplicationControllerCollection, error) {
+	return c.ListContext(context.Background(), opts)
+}
+
+func (c *ReplicationControllerClient) ListContext(ctx context.Context, opts *types.ListOpts) (*ReplicationControllerCollection, error) {
 	resp := &ReplicationControllerCollection{}
-	err := c.apiClient.Ops.DoList(ReplicationControllerType, opts, resp)
+	err := c.apiClient.Ops.DoListContext(ctx, ReplicationControllerType, opts, resp)
 	resp.client = c
 	return resp, err
 }
 
 func (cc *ReplicationControllerCollection) Next() (*ReplicationControllerCollection, error) {
+	return cc.NextContext(context.Background())
+}
+
+func (cc *ReplicationControllerCollection) NextContext(ctx context.Context) (*ReplicationControllerCollection, error) {
 	if cc != nil && cc.Pagination != nil && cc.Pagination.Next != "" {
 		resp := &ReplicationControllerCollection{}
-		err := cc.client.apiClient.Ops.DoNext(cc.Pagination.Next, resp)
+		err := cc.client.apiClient.Ops.DoNextContext(ctx, cc.Pagination.Next, resp)
 		resp.client = cc.client
 		return resp, err
 	}
@@ -169,11 +208,19 @@ func (cc *ReplicationControllerCollection) Next() (*ReplicationControllerCollect
 }
 
 func (c *ReplicationControllerClient) ByID(id string) (*ReplicationController, error) {
+	return c.ByIDContext(context.Background(), id)
+}
+
+func (c *ReplicationControllerClient) ByIDContext(ctx context.Context, id string) (*ReplicationController, error) {
 	resp := &ReplicationController{}
-	err := c.apiClient.Ops.DoByID(ReplicationControllerType, id, resp)
+	err := c.apiClient.Ops.DoByIDContext(ctx, ReplicationControllerType, id, resp)
 	return resp, err
 }
 
 func (c *ReplicationControllerClient) Delete(container *ReplicationController) error {
-	return c.apiClient.Ops.DoResourceDelete(ReplicationControllerType, &container.Resource)
+	return c.DeleteContext(context.Background(), container)
+}
+
+func (c *ReplicationControllerClient) DeleteContext(ctx context.Context, container *ReplicationController) error {
+	return c.apiClient.Ops.DoResourceDeleteContext(ctx, ReplicationControllerType, &container.Resource)
 }